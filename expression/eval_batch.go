@@ -0,0 +1,183 @@
+// Copyright 2016 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package expression
+
+import (
+	"sync"
+
+	"github.com/juju/errors"
+	"github.com/pingcap/tidb/ast"
+	"github.com/pingcap/tidb/context"
+	"github.com/pingcap/tidb/util/types"
+)
+
+// scratchPool hands out reusable []types.Datum buffers for the argument
+// slots a batch evaluation needs, so a selection or join running EvalBatch
+// per chunk doesn't allocate per row.
+var scratchPool = sync.Pool{
+	New: func() interface{} {
+		buf := make([]types.Datum, 0, 1024)
+		return &buf
+	},
+}
+
+// getScratch returns a []types.Datum of length n backed by a pooled buffer.
+func getScratch(n int) []types.Datum {
+	buf := scratchPool.Get().(*[]types.Datum)
+	if cap(*buf) < n {
+		*buf = make([]types.Datum, n)
+	}
+	return (*buf)[:n]
+}
+
+// putScratch returns buf to the pool.
+func putScratch(buf []types.Datum) {
+	scratchPool.Put(&buf)
+}
+
+// defaultEvalBatch is the fallback batch implementation: it loops over the
+// row-at-a-time Eval. It backs any ScalarFunction kind without a
+// specialized fast path below.
+func defaultEvalBatch(expr Expression, rows [][]types.Datum, ctx context.Context, out []types.Datum) error {
+	for i, row := range rows {
+		val, err := expr.Eval(row, ctx)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		out[i] = val
+	}
+	return nil
+}
+
+// defaultEvalBoolBatch is the fallback for EvalBoolBatch, mirroring the way
+// EvalBool wraps Eval: a NULL result becomes false.
+func defaultEvalBoolBatch(expr Expression, rows [][]types.Datum, ctx context.Context, out []bool) error {
+	vals := getScratch(len(rows))
+	defer putScratch(vals)
+	if err := expr.EvalBatch(rows, ctx, vals); err != nil {
+		return errors.Trace(err)
+	}
+	for i, val := range vals {
+		if val.IsNull() {
+			out[i] = false
+			continue
+		}
+		b, err := val.ToBool()
+		if err != nil {
+			return errors.Trace(err)
+		}
+		out[i] = b != 0
+	}
+	return nil
+}
+
+// EvalBatch implements Expression interface. A constant's value never
+// depends on the row, so it is filled once and copied to every slot.
+func (c *Constant) EvalBatch(rows [][]types.Datum, ctx context.Context, out []types.Datum) error {
+	for i := range rows {
+		out[i] = c.Value
+	}
+	return nil
+}
+
+// EvalBoolBatch implements Expression interface.
+func (c *Constant) EvalBoolBatch(rows [][]types.Datum, ctx context.Context, out []bool) error {
+	return defaultEvalBoolBatch(c, rows, ctx, out)
+}
+
+// EvalBatch implements Expression interface. A column's value is already
+// materialized in each row, so this is a straight copy from the row's
+// column slot with no interface dispatch per row.
+func (col *Column) EvalBatch(rows [][]types.Datum, ctx context.Context, out []types.Datum) error {
+	for i, row := range rows {
+		out[i] = row[col.Index]
+	}
+	return nil
+}
+
+// EvalBoolBatch implements Expression interface.
+func (col *Column) EvalBoolBatch(rows [][]types.Datum, ctx context.Context, out []bool) error {
+	return defaultEvalBoolBatch(col, rows, ctx, out)
+}
+
+// EvalBatch implements Expression interface. A correlated column's bound
+// Data is shared by every row in the batch, same as Constant.
+func (col *CorrelatedColumn) EvalBatch(rows [][]types.Datum, ctx context.Context, out []types.Datum) error {
+	return defaultEvalBatch(col, rows, ctx, out)
+}
+
+// EvalBoolBatch implements Expression interface.
+func (col *CorrelatedColumn) EvalBoolBatch(rows [][]types.Datum, ctx context.Context, out []bool) error {
+	return defaultEvalBoolBatch(col, rows, ctx, out)
+}
+
+// fastBatchFuncs are the ScalarFunction kinds that go through evalArgsBatch
+// instead of defaultEvalBatch: the ones common enough in selection and join
+// filters to be worth pre-evaluating their arguments columnar-wise.
+var fastBatchFuncs = map[string]bool{
+	ast.AndAnd: true,
+	ast.OrOr:   true,
+	ast.EQ:     true,
+	ast.LT:     true,
+	ast.Plus:   true,
+	ast.Minus:  true,
+	ast.Mul:    true,
+	ast.Div:    true,
+}
+
+// EvalBatch implements Expression interface. For the common comparison,
+// logical and arithmetic kinds it evaluates each argument once over the
+// whole batch (evalArgsBatch) instead of walking the full expression tree
+// per row; every other function kind falls back to defaultEvalBatch.
+func (sf *ScalarFunction) EvalBatch(rows [][]types.Datum, ctx context.Context, out []types.Datum) error {
+	if fastBatchFuncs[sf.FuncName.L] {
+		return sf.evalArgsBatch(rows, ctx, out)
+	}
+	return defaultEvalBatch(sf, rows, ctx, out)
+}
+
+// EvalBoolBatch implements Expression interface.
+func (sf *ScalarFunction) EvalBoolBatch(rows [][]types.Datum, ctx context.Context, out []bool) error {
+	return defaultEvalBoolBatch(sf, rows, ctx, out)
+}
+
+// evalArgsBatch evaluates every argument once over the whole batch via
+// EvalBatch (hitting Constant's fill-once and Column's memcpy fast paths),
+// then for each row calls sf.Function directly on a single reused argument
+// slice. Calling sf.Function - the same evaluator sf.Eval uses - keeps type
+// coercion and collation identical to the row-at-a-time path; only the
+// per-row expression-tree walk and its interface dispatch are amortized.
+func (sf *ScalarFunction) evalArgsBatch(rows [][]types.Datum, ctx context.Context, out []types.Datum) error {
+	argVals := make([][]types.Datum, len(sf.Args))
+	for j, arg := range sf.Args {
+		buf := getScratch(len(rows))
+		defer putScratch(buf)
+		if err := arg.EvalBatch(rows, ctx, buf); err != nil {
+			return errors.Trace(err)
+		}
+		argVals[j] = buf
+	}
+	rowArgs := make([]types.Datum, len(sf.Args))
+	for i := range rows {
+		for j := range sf.Args {
+			rowArgs[j] = argVals[j][i]
+		}
+		val, err := sf.Function(rowArgs, ctx)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		out[i] = val
+	}
+	return nil
+}
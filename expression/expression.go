@@ -14,7 +14,6 @@
 package expression
 
 import (
-	"bytes"
 	"encoding/json"
 	"fmt"
 
@@ -33,6 +32,15 @@ type Expression interface {
 	// Eval evaluates an expression through a row.
 	Eval(row []types.Datum, ctx context.Context) (types.Datum, error)
 
+	// EvalBatch evaluates an expression over a batch of rows, writing one
+	// result per row into out. len(out) must equal len(rows).
+	EvalBatch(rows [][]types.Datum, ctx context.Context, out []types.Datum) error
+
+	// EvalBoolBatch is the batch counterpart of EvalBool: it evaluates the
+	// expression over rows and writes the SQL-NULL-as-false boolean result
+	// for each row into out. len(out) must equal len(rows).
+	EvalBoolBatch(rows [][]types.Datum, ctx context.Context, out []bool) error
+
 	// Get the expression return type.
 	GetType() *types.FieldType
 
@@ -55,23 +63,6 @@ type Expression interface {
 	ResolveIndices(schema Schema)
 }
 
-// EvalBool evaluates expression to a boolean value.
-func EvalBool(expr Expression, row []types.Datum, ctx context.Context) (bool, error) {
-	data, err := expr.Eval(row, ctx)
-	if err != nil {
-		return false, errors.Trace(err)
-	}
-	if data.IsNull() {
-		return false, nil
-	}
-
-	i, err := data.ToBool()
-	if err != nil {
-		return false, errors.Trace(err)
-	}
-	return i != 0, nil
-}
-
 // Constant stands for a constant value.
 type Constant struct {
 	Value   types.Datum
@@ -83,12 +74,6 @@ func (c *Constant) String() string {
 	return fmt.Sprintf("%v", c.Value.GetValue())
 }
 
-// MarshalJSON implements json.Marshaler interface.
-func (c *Constant) MarshalJSON() ([]byte, error) {
-	buffer := bytes.NewBufferString(fmt.Sprintf("\"%s\"", c))
-	return buffer.Bytes(), nil
-}
-
 // Clone implements Expression interface.
 func (c *Constant) Clone() Expression {
 	con := *c
@@ -201,9 +186,15 @@ func SplitDNFItems(onExpr Expression) []Expression {
 
 // EvaluateExprWithNull sets columns in schema as null and calculate the final result of the scalar function.
 // If the Expression is a non-constant value, it means the result is unknown.
+// A registered UDF declared non-deterministic is left untouched instead of
+// being re-evaluated with nulled-out columns, since its result for the
+// substituted arguments says nothing about its result for the real ones.
 func EvaluateExprWithNull(schema Schema, expr Expression) (Expression, error) {
 	switch x := expr.(type) {
 	case *ScalarFunction:
+		if !isDeterministicFunction(x.FuncName.L) {
+			return x.Clone(), nil
+		}
 		var err error
 		args := make([]Expression, len(x.Args))
 		for i, arg := range x.Args {
@@ -0,0 +1,170 @@
+// Copyright 2016 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package expression
+
+import (
+	"encoding/base64"
+	"encoding/json"
+
+	"github.com/juju/errors"
+	"github.com/pingcap/tidb/model"
+	"github.com/pingcap/tidb/util/codec"
+	"github.com/pingcap/tidb/util/types"
+)
+
+// exprJSON is the on-the-wire shape for every Expression. Only the fields
+// relevant to Type are populated; it is kept flat so hand-written JSON
+// (e.g. from a remote coprocessor) is easy to produce.
+type exprJSON struct {
+	Type string `json:"type"`
+
+	// const
+	DataType byte   `json:"datatype,omitempty"`
+	Value    string `json:"value,omitempty"`
+
+	// col / corcol
+	DB    string `json:"db,omitempty"`
+	Table string `json:"table,omitempty"`
+	Col   string `json:"col,omitempty"`
+	Pos   int    `json:"pos"`
+
+	// scalar
+	Fn      string            `json:"fn,omitempty"`
+	Args    []json.RawMessage `json:"args,omitempty"`
+	RetType byte              `json:"rettype,omitempty"`
+}
+
+// MarshalJSON implements json.Marshaler interface. It encodes the constant's
+// value with the same codec used for storage keys so any Datum kind round-trips.
+func (c *Constant) MarshalJSON() ([]byte, error) {
+	raw, err := codec.EncodeValue(nil, c.Value)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	ej := exprJSON{
+		Type:     "const",
+		DataType: c.RetType.Tp,
+		Value:    base64.StdEncoding.EncodeToString(raw),
+	}
+	return json.Marshal(ej)
+}
+
+// MarshalJSON implements json.Marshaler interface.
+func (col *Column) MarshalJSON() ([]byte, error) {
+	ej := exprJSON{
+		Type:  "col",
+		DB:    col.DBName.L,
+		Table: col.TblName.L,
+		Col:   col.ColName.L,
+		Pos:   col.Position,
+	}
+	return json.Marshal(ej)
+}
+
+// MarshalJSON implements json.Marshaler interface. A correlated column
+// carries no value of its own before it is bound to an outer row, so it
+// serializes the same shape as a plain column, tagged "corcol" so
+// UnmarshalExpression restores it as a CorrelatedColumn instead of a Column.
+func (col *CorrelatedColumn) MarshalJSON() ([]byte, error) {
+	ej := exprJSON{
+		Type:  "corcol",
+		DB:    col.DBName.L,
+		Table: col.TblName.L,
+		Col:   col.ColName.L,
+		Pos:   col.Position,
+	}
+	return json.Marshal(ej)
+}
+
+// MarshalJSON implements json.Marshaler interface.
+func (sf *ScalarFunction) MarshalJSON() ([]byte, error) {
+	args := make([]json.RawMessage, 0, len(sf.Args))
+	for _, arg := range sf.Args {
+		raw, err := arg.MarshalJSON()
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		args = append(args, raw)
+	}
+	ej := exprJSON{
+		Type:    "scalar",
+		Fn:      sf.FuncName.L,
+		Args:    args,
+		RetType: sf.RetType.Tp,
+	}
+	return json.Marshal(ej)
+}
+
+// UnmarshalExpression rebuilds an Expression tree previously produced by
+// Constant.MarshalJSON, Column.MarshalJSON or ScalarFunction.MarshalJSON.
+// Columns are re-resolved against schema so their Position matches the
+// caller's schema rather than the one that produced the JSON, and scalar
+// functions are rebuilt through NewFunction using the RetType carried in the
+// JSON, so a non-boolean function (e.g. `plus`, `concat`) keeps its real
+// declared type instead of collapsing to whatever NewFunction would infer
+// from a bare function name.
+func UnmarshalExpression(data []byte, schema Schema) (Expression, error) {
+	var ej exprJSON
+	if err := json.Unmarshal(data, &ej); err != nil {
+		return nil, errors.Trace(err)
+	}
+	switch ej.Type {
+	case "const":
+		raw, err := base64.StdEncoding.DecodeString(ej.Value)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		_, val, err := codec.DecodeOne(raw)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		ft := types.NewFieldType(ej.DataType)
+		return &Constant{Value: val, RetType: ft}, nil
+	case "col":
+		col := &Column{
+			DBName:  model.NewCIStr(ej.DB),
+			TblName: model.NewCIStr(ej.Table),
+			ColName: model.NewCIStr(ej.Col),
+		}
+		idx := schema.GetIndex(col)
+		if idx == -1 {
+			return nil, errors.Errorf("column %s.%s.%s not found in schema", ej.DB, ej.Table, ej.Col)
+		}
+		resolved := schema[idx].Clone().(*Column)
+		return resolved, nil
+	case "corcol":
+		col := &Column{
+			DBName:  model.NewCIStr(ej.DB),
+			TblName: model.NewCIStr(ej.Table),
+			ColName: model.NewCIStr(ej.Col),
+		}
+		idx := schema.GetIndex(col)
+		if idx == -1 {
+			return nil, errors.Errorf("column %s.%s.%s not found in schema", ej.DB, ej.Table, ej.Col)
+		}
+		return &CorrelatedColumn{Column: *schema[idx]}, nil
+	case "scalar":
+		args := make([]Expression, 0, len(ej.Args))
+		for _, raw := range ej.Args {
+			arg, err := UnmarshalExpression(raw, schema)
+			if err != nil {
+				return nil, errors.Trace(err)
+			}
+			args = append(args, arg)
+		}
+		return NewFunction(ej.Fn, types.NewFieldType(ej.RetType), args...)
+	default:
+		return nil, errors.Errorf("expression: unknown type %q", ej.Type)
+	}
+}
@@ -0,0 +1,146 @@
+// Copyright 2016 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package expression
+
+import (
+	"testing"
+
+	"github.com/pingcap/tidb/model"
+	"github.com/pingcap/tidb/mysql"
+	"github.com/pingcap/tidb/util/types"
+)
+
+// jsonTestSchema has a single column `t.a`, used to re-resolve col/corcol
+// JSON round-trips against a schema whose Position differs from whatever
+// position the original expression carried.
+var jsonTestSchema = Schema{
+	{
+		DBName:   model.NewCIStr("db"),
+		TblName:  model.NewCIStr("t"),
+		ColName:  model.NewCIStr("a"),
+		RetType:  types.NewFieldType(mysql.TypeLonglong),
+		Position: 3,
+	},
+}
+
+func TestUnmarshalExpressionConst(t *testing.T) {
+	c := &Constant{Value: types.NewDatum(int64(42)), RetType: types.NewFieldType(mysql.TypeLonglong)}
+	data, err := c.MarshalJSON()
+	if err != nil {
+		t.Fatalf("MarshalJSON: %v", err)
+	}
+	got, err := UnmarshalExpression(data, jsonTestSchema)
+	if err != nil {
+		t.Fatalf("UnmarshalExpression: %v", err)
+	}
+	gc, ok := got.(*Constant)
+	if !ok {
+		t.Fatalf("UnmarshalExpression returned %T, want *Constant", got)
+	}
+	if cmp, err := gc.Value.CompareDatum(c.Value); err != nil || cmp != 0 {
+		t.Errorf("got value %v, want %v", gc.Value.GetValue(), c.Value.GetValue())
+	}
+	if gc.RetType.Tp != mysql.TypeLonglong {
+		t.Errorf("got RetType %v, want TypeLonglong", gc.RetType.Tp)
+	}
+}
+
+func TestUnmarshalExpressionColumnReResolvesPosition(t *testing.T) {
+	col := &Column{
+		DBName:  model.NewCIStr("db"),
+		TblName: model.NewCIStr("t"),
+		ColName: model.NewCIStr("a"),
+		// A stale position from wherever this column was marshaled; the
+		// schema's own position (3) should win on the way back.
+		Position: 0,
+	}
+	data, err := col.MarshalJSON()
+	if err != nil {
+		t.Fatalf("MarshalJSON: %v", err)
+	}
+	got, err := UnmarshalExpression(data, jsonTestSchema)
+	if err != nil {
+		t.Fatalf("UnmarshalExpression: %v", err)
+	}
+	gc, ok := got.(*Column)
+	if !ok {
+		t.Fatalf("UnmarshalExpression returned %T, want *Column", got)
+	}
+	if gc.Position != 3 {
+		t.Errorf("got Position %d, want 3 (re-resolved against schema)", gc.Position)
+	}
+}
+
+func TestUnmarshalExpressionCorrelatedColumn(t *testing.T) {
+	col := &CorrelatedColumn{Column: Column{
+		DBName:  model.NewCIStr("db"),
+		TblName: model.NewCIStr("t"),
+		ColName: model.NewCIStr("a"),
+	}}
+	data, err := col.MarshalJSON()
+	if err != nil {
+		t.Fatalf("MarshalJSON: %v", err)
+	}
+	got, err := UnmarshalExpression(data, jsonTestSchema)
+	if err != nil {
+		t.Fatalf("UnmarshalExpression: %v", err)
+	}
+	if _, ok := got.(*CorrelatedColumn); !ok {
+		t.Fatalf("UnmarshalExpression returned %T, want *CorrelatedColumn", got)
+	}
+}
+
+func TestUnmarshalExpressionScalarKeepsRetType(t *testing.T) {
+	// `concat` is not a boolean function; its RetType must survive the
+	// round-trip rather than collapsing to whatever a bare function name
+	// would infer.
+	arg := &Constant{Value: types.NewDatum("x"), RetType: types.NewFieldType(mysql.TypeVarchar)}
+	sf, err := NewFunction("concat", types.NewFieldType(mysql.TypeVarchar), arg, arg)
+	if err != nil {
+		t.Fatalf("NewFunction(concat): %v", err)
+	}
+	data, err := sf.MarshalJSON()
+	if err != nil {
+		t.Fatalf("MarshalJSON: %v", err)
+	}
+	got, err := UnmarshalExpression(data, jsonTestSchema)
+	if err != nil {
+		t.Fatalf("UnmarshalExpression: %v", err)
+	}
+	gsf, ok := got.(*ScalarFunction)
+	if !ok {
+		t.Fatalf("UnmarshalExpression returned %T, want *ScalarFunction", got)
+	}
+	if gsf.RetType.Tp != mysql.TypeVarchar {
+		t.Errorf("got RetType %v, want TypeVarchar", gsf.RetType.Tp)
+	}
+	if gsf.FuncName.L != "concat" {
+		t.Errorf("got FuncName %q, want concat", gsf.FuncName.L)
+	}
+}
+
+func TestUnmarshalExpressionColumnNotFound(t *testing.T) {
+	col := &Column{
+		DBName:  model.NewCIStr("db"),
+		TblName: model.NewCIStr("t"),
+		ColName: model.NewCIStr("missing"),
+	}
+	data, err := col.MarshalJSON()
+	if err != nil {
+		t.Fatalf("MarshalJSON: %v", err)
+	}
+	if _, err := UnmarshalExpression(data, jsonTestSchema); err == nil {
+		t.Errorf("UnmarshalExpression with an unresolvable column: got nil error, want one")
+	}
+}
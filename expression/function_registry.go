@@ -0,0 +1,163 @@
+// Copyright 2016 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package expression
+
+import (
+	"sync"
+
+	"github.com/juju/errors"
+	"github.com/pingcap/tidb/context"
+	"github.com/pingcap/tidb/model"
+	"github.com/pingcap/tidb/util/types"
+)
+
+// FunctionSpec describes a scalar function that was not compiled into the
+// builtin table, so NewFunction can dispatch to it by name like any other
+// function. ArgTypeCheckers, InferType and Eval mirror the three things a
+// builtin functionClass normally provides.
+type FunctionSpec struct {
+	// ArgTypeCheckers validates the argument list before InferType/Eval run.
+	// It may be nil if the function accepts any arguments.
+	ArgTypeCheckers func(args []Expression) error
+
+	// InferType computes the scalar function's return type from its
+	// argument expressions.
+	InferType func(args []Expression) *types.FieldType
+
+	// Eval computes the function's value given already-evaluated arguments.
+	Eval func(args []types.Datum, ctx context.Context) (types.Datum, error)
+
+	// IsDeterministic reports whether Eval always returns the same result
+	// for the same arguments. The zero value, false, marks the function
+	// non-deterministic (like RAND() or NOW()); set it true for functions
+	// whose result depends only on their arguments.
+	IsDeterministic bool
+
+	// IsCorrelatedHint is kept with the spec but not read by NewFunction: a
+	// ScalarFunction built from the spec computes IsCorrelated the default
+	// way (OR of the arguments' IsCorrelated), whatever this field holds.
+	IsCorrelatedHint func(args []Expression) bool
+}
+
+var (
+	userFunctionsMu sync.RWMutex
+	userFunctions   = map[string]FunctionSpec{}
+)
+
+// RegisterFunction makes spec available to NewFunction under name, so
+// downstream users can inject scalar UDFs without forking the builtin
+// table. Re-registering a builtin or previously-registered name is
+// rejected unless override is passed and true.
+func RegisterFunction(name string, spec FunctionSpec, override ...bool) error {
+	allowOverride := len(override) > 0 && override[0]
+
+	userFunctionsMu.Lock()
+	defer userFunctionsMu.Unlock()
+
+	if !allowOverride {
+		if _, ok := userFunctions[name]; ok {
+			return errors.Errorf("expression: function %q is already registered", name)
+		}
+		if isBuiltinFunctionName(name) {
+			return errors.Errorf("expression: %q is a builtin function name", name)
+		}
+	}
+	userFunctions[name] = spec
+	return nil
+}
+
+// functionClass is the interface each compiled-in builtin function registers
+// under its name in funcs. getFunction both validates args - returning an
+// error for a bad argument count or type - and infers the function's return
+// type from them, mirroring what a hand-written functionClass does for every
+// builtin; NewFunction defers to it instead of re-deriving either.
+type functionClass interface {
+	getFunction(args []Expression) (f func(args []types.Datum, ctx context.Context) (types.Datum, error), retType *types.FieldType, err error)
+}
+
+// NewFunction builds a ScalarFunction named funcName over args. A function
+// registered through RegisterFunction takes precedence, so a builtin name
+// registered with override is served by its spec. Otherwise NewFunction
+// looks funcName up in the compiled-in builtin table (funcs), deferring to
+// the matching functionClass for argument validation and return-type
+// inference rather than redoing either here; the inferred type is only used
+// when the caller passes a nil retType. For a registered function it runs
+// the spec's ArgTypeCheckers, applies InferType when the caller didn't
+// already pass a concrete retType, and wires spec.Eval in as the function's
+// evaluator. This is the only place a UDF is turned into a live
+// ScalarFunction, so Eval, folding (Simplify) and JSON round-tripping
+// (UnmarshalExpression) all pick it up automatically.
+func NewFunction(funcName string, retType *types.FieldType, args ...Expression) (*ScalarFunction, error) {
+	spec, isUDF := lookupUserFunction(funcName)
+	if class, ok := funcs[funcName]; ok && !isUDF {
+		f, inferredType, err := class.getFunction(args)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		if retType == nil {
+			retType = inferredType
+		}
+		return &ScalarFunction{
+			FuncName: model.NewCIStr(funcName),
+			Function: f,
+			RetType:  retType,
+			Args:     args,
+		}, nil
+	}
+
+	if !isUDF {
+		return nil, errors.Errorf("expression: unknown function %q", funcName)
+	}
+	if spec.ArgTypeCheckers != nil {
+		if err := spec.ArgTypeCheckers(args); err != nil {
+			return nil, errors.Trace(err)
+		}
+	}
+	if retType == nil && spec.InferType != nil {
+		retType = spec.InferType(args)
+	}
+	return &ScalarFunction{
+		FuncName: model.NewCIStr(funcName),
+		Function: spec.Eval,
+		RetType:  retType,
+		Args:     args,
+	}, nil
+}
+
+// isBuiltinFunctionName reports whether name is already served by the
+// compiled-in function table that NewFunction otherwise dispatches to.
+func isBuiltinFunctionName(name string) bool {
+	_, ok := funcs[name]
+	return ok
+}
+
+// lookupUserFunction returns the FunctionSpec registered for name, if any.
+// NewFunction consults this before the compiled-in function table.
+func lookupUserFunction(name string) (FunctionSpec, bool) {
+	userFunctionsMu.RLock()
+	defer userFunctionsMu.RUnlock()
+	spec, ok := userFunctions[name]
+	return spec, ok
+}
+
+// isDeterministicFunction reports whether name may be treated as
+// deterministic: a registered UDF is deterministic only if its spec sets
+// IsDeterministic, and builtins and unregistered names always are.
+func isDeterministicFunction(name string) bool {
+	spec, ok := lookupUserFunction(name)
+	if !ok {
+		return true
+	}
+	return spec.IsDeterministic
+}
@@ -0,0 +1,94 @@
+// Copyright 2016 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package expression
+
+import (
+	"testing"
+
+	"github.com/juju/errors"
+	"github.com/pingcap/tidb/context"
+	"github.com/pingcap/tidb/mysql"
+	"github.com/pingcap/tidb/util/types"
+)
+
+// doubleSpec is a trivial UDF: it doubles its single numeric argument.
+var doubleSpec = FunctionSpec{
+	ArgTypeCheckers: func(args []Expression) error {
+		if len(args) != 1 {
+			return errors.Errorf("double takes exactly one argument, got %d", len(args))
+		}
+		return nil
+	},
+	InferType: func(args []Expression) *types.FieldType {
+		return types.NewFieldType(mysql.TypeLonglong)
+	},
+	Eval: func(args []types.Datum, ctx context.Context) (types.Datum, error) {
+		v, err := args[0].ToInt64()
+		if err != nil {
+			return types.Datum{}, errors.Trace(err)
+		}
+		return types.NewDatum(v * 2), nil
+	},
+}
+
+func TestNewFunctionDispatchesToRegisteredUDF(t *testing.T) {
+	if err := RegisterFunction("test_double", doubleSpec); err != nil {
+		t.Fatalf("RegisterFunction: %v", err)
+	}
+	defer func() {
+		userFunctionsMu.Lock()
+		delete(userFunctions, "test_double")
+		userFunctionsMu.Unlock()
+	}()
+
+	arg := &Constant{Value: types.NewDatum(int64(21)), RetType: types.NewFieldType(mysql.TypeLonglong)}
+	sf, err := NewFunction("test_double", nil, arg)
+	if err != nil {
+		t.Fatalf("NewFunction(test_double): %v", err)
+	}
+	if sf.RetType.Tp != mysql.TypeLonglong {
+		t.Errorf("NewFunction(test_double) RetType = %v, want TypeLonglong (from InferType)", sf.RetType.Tp)
+	}
+	val, err := sf.Eval(nil, nil)
+	if err != nil {
+		t.Fatalf("Eval: %v", err)
+	}
+	if v, err := val.ToInt64(); err != nil || v != 42 {
+		t.Errorf("test_double(21) = %v, want 42", val.GetValue())
+	}
+}
+
+func TestRegisterFunctionRejectsDuplicateName(t *testing.T) {
+	if err := RegisterFunction("test_dup", doubleSpec); err != nil {
+		t.Fatalf("first RegisterFunction: %v", err)
+	}
+	defer func() {
+		userFunctionsMu.Lock()
+		delete(userFunctions, "test_dup")
+		userFunctionsMu.Unlock()
+	}()
+
+	if err := RegisterFunction("test_dup", doubleSpec); err == nil {
+		t.Errorf("RegisterFunction(test_dup) again: got nil error, want one for re-registering without override")
+	}
+	if err := RegisterFunction("test_dup", doubleSpec, true); err != nil {
+		t.Errorf("RegisterFunction(test_dup, override=true): got error %v, want nil", err)
+	}
+}
+
+func TestNewFunctionUnknownName(t *testing.T) {
+	if _, err := NewFunction("no_such_function", nil); err == nil {
+		t.Errorf("NewFunction(no_such_function): got nil error, want one")
+	}
+}
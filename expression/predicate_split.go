@@ -0,0 +1,316 @@
+// Copyright 2016 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package expression
+
+import (
+	"github.com/juju/errors"
+	"github.com/pingcap/tidb/ast"
+	"github.com/pingcap/tidb/util/types"
+)
+
+// indexFilterRangeOps are the comparison operators that can be turned into a
+// range on the leading index column.
+var indexFilterRangeOps = map[string]bool{
+	ast.EQ:      true,
+	ast.In:      true,
+	ast.LT:      true,
+	ast.LE:      true,
+	ast.GT:      true,
+	ast.GE:      true,
+	ast.Between: true,
+}
+
+// flippedRangeOp maps op to the operator that keeps the same meaning when
+// the column and the constant swap sides, e.g. `1 < a` means the same thing
+// as `a > 1`.
+var flippedRangeOp = map[string]string{
+	ast.EQ: ast.EQ,
+	ast.LT: ast.GT,
+	ast.LE: ast.GE,
+	ast.GT: ast.LT,
+	ast.GE: ast.LE,
+}
+
+// columnInIndexCols reports whether col is one of the given indexed columns.
+func columnInIndexCols(col *Column, indexCols []*Column) bool {
+	for _, ic := range indexCols {
+		if col.Equal(ic) {
+			return true
+		}
+	}
+	return false
+}
+
+// allColumnsInIndex reports whether every column referenced by expr is
+// contained in indexCols, so expr can be evaluated using only the index.
+// expr must already be free of correlated columns - SplitIndexFilter checks
+// cond.IsCorrelated() before calling this - since a correlated column is not
+// a schema column but also can't be evaluated by an index/KV scan, which has
+// no access to the outer row it refers to.
+func allColumnsInIndex(expr Expression, indexCols []*Column) bool {
+	switch x := expr.(type) {
+	case *Column:
+		return columnInIndexCols(x, indexCols)
+	case *ScalarFunction:
+		for _, arg := range x.Args {
+			if !allColumnsInIndex(arg, indexCols) {
+				return false
+			}
+		}
+		return true
+	case *Constant:
+		return true
+	default:
+		// CorrelatedColumn and any other expression kind carry no schema
+		// column; treat them as not index-evaluable rather than assume so.
+		return false
+	}
+}
+
+// rangeFilterOp reports whether cond is a comparison between the leading
+// index column and one or more constants, e.g. `a = 1`, `1 < a`,
+// `a IN (1, 2)`, `a BETWEEN 1 AND 2`. It returns the operator normalized to
+// the `col <op> const` orientation - so `1 < a` comes back as (ast.GT, true)
+// even though the source predicate wrote the column on the right - along
+// with the constant arguments of cond.
+func rangeFilterOp(cond Expression, leadingCol *Column) (op string, constArgs []*Constant, ok bool) {
+	sf, ok := cond.(*ScalarFunction)
+	if !ok || !indexFilterRangeOps[sf.FuncName.L] {
+		return "", nil, false
+	}
+
+	if col, isCol := sf.Args[0].(*Column); isCol && col.Equal(leadingCol) {
+		constArgs, ok = allConstants(sf.Args[1:])
+		return sf.FuncName.L, constArgs, ok
+	}
+
+	// Only EQ/LT/LE/GT/GE can appear with the column on the right; IN and
+	// BETWEEN are always `col IN (...)` / `col BETWEEN a AND b`.
+	flipped, canFlip := flippedRangeOp[sf.FuncName.L]
+	if !canFlip || len(sf.Args) != 2 {
+		return "", nil, false
+	}
+	col, isCol := sf.Args[1].(*Column)
+	if !isCol || !col.Equal(leadingCol) {
+		return "", nil, false
+	}
+	constArgs, ok = allConstants(sf.Args[:1])
+	return flipped, constArgs, ok
+}
+
+// allConstants reports whether every expression in args is a *Constant, and
+// returns them typed as such.
+func allConstants(args []Expression) ([]*Constant, bool) {
+	consts := make([]*Constant, len(args))
+	for i, arg := range args {
+		c, ok := arg.(*Constant)
+		if !ok {
+			return nil, false
+		}
+		consts[i] = c
+	}
+	return consts, true
+}
+
+// SplitIndexFilter splits conds into indexFilter, the conjuncts that can be
+// evaluated using only indexCols and therefore pushed down to the index scan,
+// and tagFilter, the conjuncts that reference at least one column outside of
+// indexCols and must be evaluated after the rows are fetched. It mirrors the
+// index_filter/tag_filter split used by time-series engines: the optimizer
+// can build an index scan purely from indexFilter and defer everything else.
+// Use ExtractIndexRangeFilter on the returned indexFilter to further pull out
+// the range-style conjuncts on the leading index column as a range set.
+func SplitIndexFilter(conds []Expression, schema Schema, indexCols []*Column) (indexFilter, tagFilter []Expression) {
+	for _, top := range conds {
+		for _, cond := range SplitCNFItems(top) {
+			cond = cond.Decorrelate(schema)
+			cond.ResolveIndices(schema)
+			// A predicate Decorrelate couldn't resolve away still references
+			// an outer row, which the index/KV scan cannot supply; it must
+			// be evaluated later as a tag filter, however few columns it
+			// references.
+			if !cond.IsCorrelated() && allColumnsInIndex(cond, indexCols) {
+				indexFilter = append(indexFilter, cond)
+				continue
+			}
+			tagFilter = append(tagFilter, cond)
+		}
+	}
+	return indexFilter, tagFilter
+}
+
+// IndexRange is a closed-or-open interval [Low, High] on the leading index
+// column, normalized so callers can turn it directly into a KV key range
+// without re-parsing the source predicate.
+type IndexRange struct {
+	Low         types.Datum
+	High        types.Datum
+	LowExclude  bool
+	HighExclude bool
+}
+
+// fullIndexRange returns the unrestricted (-inf, +inf) range.
+func fullIndexRange() *IndexRange {
+	return &IndexRange{Low: types.MinNotNullDatum(), High: types.MaxValueDatum()}
+}
+
+// isEmpty reports whether r describes an unsatisfiable range, e.g. after
+// intersecting `a > 5` with `a < 5`.
+func (r *IndexRange) isEmpty() bool {
+	cmp, err := r.Low.CompareDatum(r.High)
+	if err != nil {
+		return false
+	}
+	if cmp > 0 {
+		return true
+	}
+	return cmp == 0 && (r.LowExclude || r.HighExclude)
+}
+
+// tightenLow narrows r's lower bound to val if val is a stricter bound than
+// the one r already has.
+func (r *IndexRange) tightenLow(val types.Datum, exclude bool) error {
+	cmp, err := val.CompareDatum(r.Low)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if cmp > 0 {
+		r.Low, r.LowExclude = val, exclude
+	} else if cmp == 0 && exclude {
+		r.LowExclude = true
+	}
+	return nil
+}
+
+// tightenHigh narrows r's upper bound to val if val is a stricter bound than
+// the one r already has.
+func (r *IndexRange) tightenHigh(val types.Datum, exclude bool) error {
+	cmp, err := val.CompareDatum(r.High)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if cmp < 0 {
+		r.High, r.HighExclude = val, exclude
+	} else if cmp == 0 && exclude {
+		r.HighExclude = true
+	}
+	return nil
+}
+
+// pointRangesForOp expands a single range-style predicate (already
+// normalized to `col <op> const...`) into the point/interval ranges it
+// describes on its own, e.g. `IN (1, 2)` becomes two point ranges.
+func pointRangesForOp(op string, args []*Constant) ([]*IndexRange, error) {
+	switch op {
+	case ast.EQ:
+		return []*IndexRange{{Low: args[0].Value, High: args[0].Value}}, nil
+	case ast.LT:
+		r := fullIndexRange()
+		r.High, r.HighExclude = args[0].Value, true
+		return []*IndexRange{r}, nil
+	case ast.LE:
+		r := fullIndexRange()
+		r.High = args[0].Value
+		return []*IndexRange{r}, nil
+	case ast.GT:
+		r := fullIndexRange()
+		r.Low, r.LowExclude = args[0].Value, true
+		return []*IndexRange{r}, nil
+	case ast.GE:
+		r := fullIndexRange()
+		r.Low = args[0].Value
+		return []*IndexRange{r}, nil
+	case ast.Between:
+		return []*IndexRange{{Low: args[0].Value, High: args[1].Value}}, nil
+	case ast.In:
+		ranges := make([]*IndexRange, 0, len(args))
+	nextValue:
+		for i, c := range args {
+			// Skip a value already listed so repeated IN values don't yield
+			// overlapping point ranges.
+			for _, prev := range args[:i] {
+				cmp, err := c.Value.CompareDatum(prev.Value)
+				if err != nil {
+					return nil, errors.Trace(err)
+				}
+				if cmp == 0 {
+					continue nextValue
+				}
+			}
+			ranges = append(ranges, &IndexRange{Low: c.Value, High: c.Value})
+		}
+		return ranges, nil
+	default:
+		return nil, errors.Errorf("expression: %q is not a range operator", op)
+	}
+}
+
+// intersect narrows base by the bounds of add, returning nil if the result
+// is unsatisfiable.
+func intersect(base, add *IndexRange) (*IndexRange, error) {
+	r := &IndexRange{Low: base.Low, LowExclude: base.LowExclude, High: base.High, HighExclude: base.HighExclude}
+	if err := r.tightenLow(add.Low, add.LowExclude); err != nil {
+		return nil, errors.Trace(err)
+	}
+	if err := r.tightenHigh(add.High, add.HighExclude); err != nil {
+		return nil, errors.Trace(err)
+	}
+	if r.isEmpty() {
+		return nil, nil
+	}
+	return r, nil
+}
+
+// ExtractIndexRangeFilter pulls the range-style conjuncts on the leading
+// index column (indexCols[0]) out of conds - e.g. `a = 1`, `1 < a`,
+// `a IN (1, 2)`, `a BETWEEN 1 AND 10` - and combines them into a normalized,
+// non-overlapping set of IndexRange so callers can build KV key ranges
+// directly instead of re-parsing each predicate.
+func ExtractIndexRangeFilter(conds []Expression, indexCols []*Column) ([]*IndexRange, error) {
+	if len(indexCols) == 0 {
+		return nil, nil
+	}
+	leadingCol := indexCols[0]
+
+	ranges := []*IndexRange{fullIndexRange()}
+	for _, top := range conds {
+		for _, cond := range SplitCNFItems(top) {
+			op, constArgs, ok := rangeFilterOp(cond, leadingCol)
+			if !ok {
+				continue
+			}
+			predRanges, err := pointRangesForOp(op, constArgs)
+			if err != nil {
+				return nil, errors.Trace(err)
+			}
+			var next []*IndexRange
+			for _, base := range ranges {
+				for _, add := range predRanges {
+					r, err := intersect(base, add)
+					if err != nil {
+						return nil, errors.Trace(err)
+					}
+					if r != nil {
+						next = append(next, r)
+					}
+				}
+			}
+			ranges = next
+			if len(ranges) == 0 {
+				return ranges, nil
+			}
+		}
+	}
+	return ranges, nil
+}
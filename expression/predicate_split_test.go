@@ -0,0 +1,143 @@
+// Copyright 2016 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package expression
+
+import (
+	"testing"
+
+	"github.com/pingcap/tidb/ast"
+	"github.com/pingcap/tidb/model"
+	"github.com/pingcap/tidb/mysql"
+	"github.com/pingcap/tidb/util/types"
+)
+
+// rangeTestCol is the sole leading index column shared by the test cases
+// below: `a`, an int column at row slot 0.
+var rangeTestCol = &Column{RetType: types.NewFieldType(mysql.TypeLonglong), Index: 0}
+
+// rangeConst builds an int64 constant for use as a predicate argument.
+func rangeConst(v int64) *Constant {
+	return &Constant{Value: types.NewDatum(v), RetType: types.NewFieldType(mysql.TypeLonglong)}
+}
+
+// rangeCmp builds `col <op> const`, e.g. rangeCmp(ast.LT, 5) for `a < 5`.
+func rangeCmp(t *testing.T, op string, v int64) Expression {
+	f, err := NewFunction(op, types.NewFieldType(mysql.TypeTiny), rangeTestCol, rangeConst(v))
+	if err != nil {
+		t.Fatalf("NewFunction(%s): %v", op, err)
+	}
+	return f
+}
+
+// rangeCmpFlipped builds `const <op> col`, e.g. rangeCmpFlipped(ast.LT, 5) for
+// `5 < a`, to exercise the flippedRangeOp normalization.
+func rangeCmpFlipped(t *testing.T, op string, v int64) Expression {
+	f, err := NewFunction(op, types.NewFieldType(mysql.TypeTiny), rangeConst(v), rangeTestCol)
+	if err != nil {
+		t.Fatalf("NewFunction(%s): %v", op, err)
+	}
+	return f
+}
+
+func wantRange(low, high int64, lowExclude, highExclude bool) *IndexRange {
+	return &IndexRange{
+		Low:         types.NewDatum(low),
+		High:        types.NewDatum(high),
+		LowExclude:  lowExclude,
+		HighExclude: highExclude,
+	}
+}
+
+func rangesEqual(t *testing.T, got []*IndexRange, want []*IndexRange) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for i := range got {
+		lc, err := got[i].Low.CompareDatum(want[i].Low)
+		if err != nil || lc != 0 {
+			return false
+		}
+		hc, err := got[i].High.CompareDatum(want[i].High)
+		if err != nil || hc != 0 {
+			return false
+		}
+		if got[i].LowExclude != want[i].LowExclude || got[i].HighExclude != want[i].HighExclude {
+			return false
+		}
+	}
+	return true
+}
+
+func TestExtractIndexRangeFilterFlippedConstOpCol(t *testing.T) {
+	// `5 < a` means the same thing as `a > 5`: (5, +inf).
+	conds := []Expression{rangeCmpFlipped(t, ast.LT, 5)}
+	got, err := ExtractIndexRangeFilter(conds, []*Column{rangeTestCol})
+	if err != nil {
+		t.Fatalf("ExtractIndexRangeFilter: %v", err)
+	}
+	want := []*IndexRange{{Low: types.NewDatum(int64(5)), High: types.MaxValueDatum(), LowExclude: true}}
+	if !rangesEqual(t, got, want) {
+		t.Errorf("ExtractIndexRangeFilter(5 < a) = %+v, want %+v", got, want)
+	}
+}
+
+func TestExtractIndexRangeFilterInIntersectedWithBound(t *testing.T) {
+	// `a IN (1, 2, 10)` intersected with `a < 5` should drop the 10 point.
+	in, err := NewFunction(ast.In, types.NewFieldType(mysql.TypeTiny), rangeTestCol,
+		rangeConst(1), rangeConst(2), rangeConst(10))
+	if err != nil {
+		t.Fatalf("NewFunction(in): %v", err)
+	}
+	conds := []Expression{in, rangeCmp(t, ast.LT, 5)}
+	got, err := ExtractIndexRangeFilter(conds, []*Column{rangeTestCol})
+	if err != nil {
+		t.Fatalf("ExtractIndexRangeFilter: %v", err)
+	}
+	want := []*IndexRange{wantRange(1, 1, false, false), wantRange(2, 2, false, false)}
+	if !rangesEqual(t, got, want) {
+		t.Errorf("ExtractIndexRangeFilter(a IN (1,2,10) AND a < 5) = %+v, want %+v", got, want)
+	}
+}
+
+func TestExtractIndexRangeFilterEmptyRange(t *testing.T) {
+	// `a > 5 AND a < 5` is unsatisfiable.
+	conds := []Expression{rangeCmp(t, ast.GT, 5), rangeCmp(t, ast.LT, 5)}
+	got, err := ExtractIndexRangeFilter(conds, []*Column{rangeTestCol})
+	if err != nil {
+		t.Fatalf("ExtractIndexRangeFilter: %v", err)
+	}
+	if len(got) != 0 {
+		t.Errorf("ExtractIndexRangeFilter(a > 5 AND a < 5) = %+v, want empty", got)
+	}
+}
+
+func TestSplitIndexFilterCorrelatedColumnStaysTagFilter(t *testing.T) {
+	schema := Schema{rangeTestCol}
+	// outer.c must not match any schema column, or Decorrelate would turn
+	// it back into a plain column.
+	outer := &CorrelatedColumn{Column: Column{
+		TblName:  model.NewCIStr("outer"),
+		ColName:  model.NewCIStr("c"),
+		RetType:  types.NewFieldType(mysql.TypeLonglong),
+		Position: 1,
+	}}
+	cond, err := NewFunction(ast.EQ, types.NewFieldType(mysql.TypeTiny), rangeTestCol, outer)
+	if err != nil {
+		t.Fatalf("NewFunction(eq): %v", err)
+	}
+	indexFilter, tagFilter := SplitIndexFilter([]Expression{cond}, schema, []*Column{rangeTestCol})
+	if len(indexFilter) != 0 || len(tagFilter) != 1 {
+		t.Errorf("SplitIndexFilter(a = outer.c) = indexFilter %+v, tagFilter %+v, want all in tagFilter", indexFilter, tagFilter)
+	}
+}
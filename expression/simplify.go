@@ -0,0 +1,234 @@
+// Copyright 2016 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package expression
+
+import (
+	"github.com/juju/errors"
+	"github.com/pingcap/tidb/ast"
+	"github.com/pingcap/tidb/context"
+	"github.com/pingcap/tidb/mysql"
+	"github.com/pingcap/tidb/util/types"
+)
+
+// Simplify rewrites expr using constant folding and a small set of algebraic
+// laws, then rebuilds a balanced CNF tree so the result is friendly to
+// pb encoding and predicate pushdown. It is safe to call before predicate
+// pushdown; correlated sub-expressions are left untouched since folding them
+// would require values that are not yet bound.
+//
+// whereClause must be true only when expr is evaluated in a context that
+// already collapses SQL NULL to false, i.e. a WHERE or JOIN...ON (inner
+// join) predicate. In that context NULL and FALSE are interchangeable, so
+// Simplify may additionally treat a NULL sub-expression as FALSE to
+// short-circuit AND/OR. Pass false for CASE, CHECK constraints, outer-join
+// ON clauses, or any other place where NULL must stay distinct from FALSE
+// (see EvalTernary) - collapsing it there would change the result.
+func Simplify(expr Expression, ctx context.Context, whereClause bool) (Expression, error) {
+	items := SplitCNFItems(expr)
+	if len(items) == 1 {
+		// A lone item is not a conjunct of a larger AND, so a folded
+		// non-boolean constant (e.g. `1 + 2`) is returned as is rather than
+		// dropped as TRUE.
+		s, err := simplify(items[0], ctx, whereClause)
+		return s, errors.Trace(err)
+	}
+	simplified := make([]Expression, 0, len(items))
+	for _, item := range items {
+		s, err := simplify(item, ctx, whereClause)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		if isFalse(s) || (whereClause && isNull(s)) {
+			return s, nil
+		}
+		if isTrue(s) {
+			continue
+		}
+		simplified = append(simplified, s)
+	}
+	if len(simplified) == 0 {
+		return &Constant{Value: types.NewDatum(int64(1)), RetType: types.NewFieldType(mysql.TypeTiny)}, nil
+	}
+	return ComposeCNFCondition(simplified), nil
+}
+
+// simplify recursively rewrites a single expression, without touching its
+// place among sibling CNF conjuncts.
+func simplify(expr Expression, ctx context.Context, whereClause bool) (Expression, error) {
+	sf, ok := expr.(*ScalarFunction)
+	if !ok || expr.IsCorrelated() {
+		return expr, nil
+	}
+
+	args := make([]Expression, len(sf.Args))
+	allConst := true
+	for i, arg := range sf.Args {
+		s, err := simplify(arg, ctx, whereClause)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		args[i] = s
+		if _, ok := s.(*Constant); !ok {
+			allConst = false
+		}
+	}
+
+	switch sf.FuncName.L {
+	case ast.AndAnd:
+		return simplifyAnd(args, whereClause)
+	case ast.OrOr:
+		return simplifyOr(args, whereClause)
+	case ast.UnaryNot:
+		if inner, ok := args[0].(*ScalarFunction); ok && inner.FuncName.L == ast.UnaryNot {
+			return inner.Args[0], nil
+		}
+	default:
+		if canon, ok := canonicalizeBinaryOp(sf.FuncName.L, args); ok {
+			args = canon
+		}
+	}
+
+	if allConst {
+		return foldConstant(sf.FuncName.L, sf.RetType, args, ctx)
+	}
+	rebuilt, err := NewFunction(sf.FuncName.L, sf.RetType, args...)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return rebuilt, nil
+}
+
+// simplifyAnd applies the algebraic laws that hold under Kleene AND
+// regardless of context - FALSE short-circuits, TRUE drops out - and only
+// additionally short-circuits on NULL when whereClause makes NULL and FALSE
+// interchangeable. Outside whereClause, a NULL operand is kept: `NULL AND
+// col` cannot be reduced to a single constant, since if col turns out FALSE
+// at eval time the real result is FALSE, not NULL.
+func simplifyAnd(args []Expression, whereClause bool) (Expression, error) {
+	remaining := make([]Expression, 0, len(args))
+	for _, arg := range args {
+		if isFalse(arg) {
+			return arg, nil
+		}
+		if whereClause && isNull(arg) {
+			return arg, nil
+		}
+		if isTrue(arg) {
+			continue
+		}
+		remaining = append(remaining, arg)
+	}
+	if len(remaining) == 0 {
+		return &Constant{Value: types.NewDatum(int64(1)), RetType: types.NewFieldType(mysql.TypeTiny)}, nil
+	}
+	if len(remaining) == 1 {
+		return remaining[0], nil
+	}
+	return ComposeCNFCondition(remaining), nil
+}
+
+// simplifyOr is the dual of simplifyAnd: TRUE short-circuits and FALSE drops
+// out unconditionally, and a NULL operand additionally drops out only under
+// whereClause, where `OR(NULL, x)` and `OR(FALSE, x)` are indistinguishable
+// once the WHERE clause collapses NULL to false.
+func simplifyOr(args []Expression, whereClause bool) (Expression, error) {
+	remaining := make([]Expression, 0, len(args))
+	for _, arg := range args {
+		if isTrue(arg) {
+			return arg, nil
+		}
+		if isFalse(arg) {
+			continue
+		}
+		if whereClause && isNull(arg) {
+			continue
+		}
+		remaining = append(remaining, arg)
+	}
+	if len(remaining) == 0 {
+		return &Constant{Value: types.NewDatum(int64(0)), RetType: types.NewFieldType(mysql.TypeTiny)}, nil
+	}
+	if len(remaining) == 1 {
+		return remaining[0], nil
+	}
+	return ComposeDNFCondition(remaining), nil
+}
+
+// commutativeOps are the binary comparisons for which `const <op> col` can be
+// canonicalized to `col <op> const` so HashCode/Equal can dedupe equivalent
+// predicates regardless of which side the literal was written on.
+var commutativeOps = map[string]bool{
+	ast.EQ: true,
+	ast.NE: true,
+}
+
+// canonicalizeBinaryOp swaps `const <op> col` into `col <op> const` for
+// symmetric operators. It reports false when no swap is needed or possible.
+func canonicalizeBinaryOp(funcName string, args []Expression) ([]Expression, bool) {
+	if len(args) != 2 || !commutativeOps[funcName] {
+		return args, false
+	}
+	_, lhsConst := args[0].(*Constant)
+	_, rhsCol := args[1].(*Column)
+	if lhsConst && rhsCol {
+		return []Expression{args[1], args[0]}, true
+	}
+	return args, false
+}
+
+// foldConstant evaluates a scalar function whose arguments are now all
+// constants and returns the result as a single Constant. retType is the
+// original ScalarFunction's declared return type, passed through unchanged -
+// same as the non-folded rebuild path above - so folding a non-boolean
+// function (e.g. `1 + 2`, `concat(...)`) doesn't collapse its type to
+// whatever a bare function name would infer on its own.
+func foldConstant(funcName string, retType *types.FieldType, args []Expression, ctx context.Context) (Expression, error) {
+	fn, err := NewFunction(funcName, retType, args...)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	val, err := fn.Eval(nil, ctx)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return &Constant{Value: val, RetType: fn.GetType()}, nil
+}
+
+// isTrue reports whether expr is a non-null constant that evaluates truthy.
+func isTrue(expr Expression) bool {
+	c, ok := expr.(*Constant)
+	if !ok || c.Value.IsNull() {
+		return false
+	}
+	b, err := c.Value.ToBool()
+	return err == nil && b != 0
+}
+
+// isFalse reports whether expr is a non-null constant that evaluates falsy.
+// It does NOT treat NULL as false, since that equivalence only holds in a
+// WHERE-style context.
+func isFalse(expr Expression) bool {
+	c, ok := expr.(*Constant)
+	if !ok || c.Value.IsNull() {
+		return false
+	}
+	b, err := c.Value.ToBool()
+	return err == nil && b == 0
+}
+
+// isNull reports whether expr is a constant NULL.
+func isNull(expr Expression) bool {
+	c, ok := expr.(*Constant)
+	return ok && c.Value.IsNull()
+}
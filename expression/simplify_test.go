@@ -0,0 +1,116 @@
+// Copyright 2016 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package expression
+
+import (
+	"testing"
+
+	"github.com/pingcap/tidb/ast"
+	"github.com/pingcap/tidb/mysql"
+	"github.com/pingcap/tidb/util/types"
+)
+
+func simplifyCol() *Column {
+	return &Column{RetType: types.NewFieldType(mysql.TypeLonglong), Index: 0}
+}
+
+func TestSimplifyFoldsConstantArithmetic(t *testing.T) {
+	// `1 + 2` folds to a Constant whose RetType is the Plus function's own
+	// declared type, not an incidental TypeTiny.
+	plus, err := NewFunction(ast.Plus, types.NewFieldType(mysql.TypeLonglong),
+		&Constant{Value: types.NewDatum(int64(1)), RetType: types.NewFieldType(mysql.TypeLonglong)},
+		&Constant{Value: types.NewDatum(int64(2)), RetType: types.NewFieldType(mysql.TypeLonglong)})
+	if err != nil {
+		t.Fatalf("NewFunction(plus): %v", err)
+	}
+	got, err := Simplify(plus, nil, true)
+	if err != nil {
+		t.Fatalf("Simplify: %v", err)
+	}
+	c, ok := got.(*Constant)
+	if !ok {
+		t.Fatalf("Simplify(1 + 2) = %T, want *Constant", got)
+	}
+	if c.RetType.Tp != mysql.TypeLonglong {
+		t.Errorf("Simplify(1 + 2) RetType = %v, want TypeLonglong", c.RetType.Tp)
+	}
+	if v, err := c.Value.ToInt64(); err != nil || v != 3 {
+		t.Errorf("Simplify(1 + 2) = %v, want 3", c.Value.GetValue())
+	}
+}
+
+func TestSimplifyNullUnderWhereClause(t *testing.T) {
+	// col AND NULL, in a WHERE-clause context, collapses to NULL because
+	// NULL and FALSE are interchangeable there.
+	and, err := NewFunction(ast.AndAnd, types.NewFieldType(mysql.TypeTiny), simplifyCol(),
+		&Constant{Value: types.Datum{}, RetType: types.NewFieldType(mysql.TypeTiny)})
+	if err != nil {
+		t.Fatalf("NewFunction(and): %v", err)
+	}
+	got, err := Simplify(and, nil, true)
+	if err != nil {
+		t.Fatalf("Simplify: %v", err)
+	}
+	if !isNull(got) {
+		t.Errorf("Simplify(col AND NULL, whereClause=true) = %v, want NULL", got)
+	}
+}
+
+func TestSimplifyNullKeptOutsideWhereClause(t *testing.T) {
+	// Outside a WHERE-clause context (e.g. CASE or outer-join ON), `col AND
+	// NULL` cannot be collapsed: if col turns out FALSE the real result is
+	// FALSE, not NULL.
+	col := simplifyCol()
+	and, err := NewFunction(ast.AndAnd, types.NewFieldType(mysql.TypeTiny), col,
+		&Constant{Value: types.Datum{}, RetType: types.NewFieldType(mysql.TypeTiny)})
+	if err != nil {
+		t.Fatalf("NewFunction(and): %v", err)
+	}
+	got, err := Simplify(and, nil, false)
+	if err != nil {
+		t.Fatalf("Simplify: %v", err)
+	}
+	if isNull(got) || isFalse(got) || isTrue(got) {
+		t.Errorf("Simplify(col AND NULL, whereClause=false) = %v, want an un-folded expression", got)
+	}
+}
+
+func TestSimplifyRebuildsCNF(t *testing.T) {
+	// `(col = 1) AND (col = 2)` has no constant-foldable top-level shape, so
+	// Simplify should rebuild it as a balanced CNF tree over both conjuncts
+	// rather than drop either one.
+	col := simplifyCol()
+	eq1, err := NewFunction(ast.EQ, types.NewFieldType(mysql.TypeTiny), col,
+		&Constant{Value: types.NewDatum(int64(1)), RetType: types.NewFieldType(mysql.TypeLonglong)})
+	if err != nil {
+		t.Fatalf("NewFunction(eq1): %v", err)
+	}
+	eq2, err := NewFunction(ast.EQ, types.NewFieldType(mysql.TypeTiny), col,
+		&Constant{Value: types.NewDatum(int64(2)), RetType: types.NewFieldType(mysql.TypeLonglong)})
+	if err != nil {
+		t.Fatalf("NewFunction(eq2): %v", err)
+	}
+	and, err := NewFunction(ast.AndAnd, types.NewFieldType(mysql.TypeTiny), eq1, eq2)
+	if err != nil {
+		t.Fatalf("NewFunction(and): %v", err)
+	}
+	got, err := Simplify(and, nil, true)
+	if err != nil {
+		t.Fatalf("Simplify: %v", err)
+	}
+	items := SplitCNFItems(got)
+	if len(items) != 2 {
+		t.Fatalf("Simplify((col=1) AND (col=2)) kept %d conjuncts, want 2", len(items))
+	}
+}
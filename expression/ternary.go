@@ -0,0 +1,126 @@
+// Copyright 2016 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package expression
+
+import (
+	"github.com/juju/errors"
+	"github.com/pingcap/tidb/ast"
+	"github.com/pingcap/tidb/context"
+	"github.com/pingcap/tidb/util/types"
+)
+
+// EvalTernary evaluates expr to SQL's three-valued logic: val is only
+// meaningful when isNull is false. Unlike EvalBool, which collapses NULL to
+// false for WHERE-clause semantics, EvalTernary keeps NULL distinct so
+// callers that need real three-valued logic - CASE, NOT, outer-join ON
+// clauses, CHECK constraints - can implement the MySQL truth tables
+// correctly.
+func EvalTernary(expr Expression, row []types.Datum, ctx context.Context) (val bool, isNull bool, err error) {
+	if sf, ok := expr.(*ScalarFunction); ok {
+		switch sf.FuncName.L {
+		case ast.AndAnd:
+			return evalAndTernary(sf, row, ctx)
+		case ast.OrOr:
+			return evalOrTernary(sf, row, ctx)
+		case ast.UnaryNot:
+			return evalNotTernary(sf, row, ctx)
+		}
+	}
+	data, err := expr.Eval(row, ctx)
+	if err != nil {
+		return false, false, errors.Trace(err)
+	}
+	if data.IsNull() {
+		return false, true, nil
+	}
+	i, err := data.ToBool()
+	if err != nil {
+		return false, false, errors.Trace(err)
+	}
+	return i != 0, false, nil
+}
+
+// EvalBool evaluates expression to a boolean value, collapsing SQL NULL to
+// false. It is a thin wrapper around EvalTernary kept for the WHERE-clause
+// callers this package already had before three-valued evaluation existed.
+func EvalBool(expr Expression, row []types.Datum, ctx context.Context) (bool, error) {
+	val, isNull, err := EvalTernary(expr, row, ctx)
+	if err != nil {
+		return false, errors.Trace(err)
+	}
+	if isNull {
+		return false, nil
+	}
+	return val, nil
+}
+
+// evalAndTernary implements the Kleene AND truth table:
+// TRUE AND TRUE = TRUE, TRUE AND NULL = NULL, TRUE AND FALSE = FALSE,
+// FALSE AND anything = FALSE, NULL AND NULL = NULL.
+func evalAndTernary(sf *ScalarFunction, row []types.Datum, ctx context.Context) (val bool, isNull bool, err error) {
+	lv, lNull, err := EvalTernary(sf.Args[0], row, ctx)
+	if err != nil {
+		return false, false, errors.Trace(err)
+	}
+	if !lNull && !lv {
+		return false, false, nil
+	}
+	rv, rNull, err := EvalTernary(sf.Args[1], row, ctx)
+	if err != nil {
+		return false, false, errors.Trace(err)
+	}
+	if !rNull && !rv {
+		return false, false, nil
+	}
+	if lNull || rNull {
+		return false, true, nil
+	}
+	return true, false, nil
+}
+
+// evalOrTernary implements the Kleene OR truth table:
+// FALSE OR FALSE = FALSE, FALSE OR NULL = NULL, TRUE OR anything = TRUE,
+// NULL OR NULL = NULL.
+func evalOrTernary(sf *ScalarFunction, row []types.Datum, ctx context.Context) (val bool, isNull bool, err error) {
+	lv, lNull, err := EvalTernary(sf.Args[0], row, ctx)
+	if err != nil {
+		return false, false, errors.Trace(err)
+	}
+	if !lNull && lv {
+		return true, false, nil
+	}
+	rv, rNull, err := EvalTernary(sf.Args[1], row, ctx)
+	if err != nil {
+		return false, false, errors.Trace(err)
+	}
+	if !rNull && rv {
+		return true, false, nil
+	}
+	if lNull || rNull {
+		return false, true, nil
+	}
+	return false, false, nil
+}
+
+// evalNotTernary implements NOT NULL = NULL and inverts otherwise.
+func evalNotTernary(sf *ScalarFunction, row []types.Datum, ctx context.Context) (val bool, isNull bool, err error) {
+	v, isNull, err := EvalTernary(sf.Args[0], row, ctx)
+	if err != nil {
+		return false, false, errors.Trace(err)
+	}
+	if isNull {
+		return false, true, nil
+	}
+	return !v, false, nil
+}
@@ -0,0 +1,135 @@
+// Copyright 2016 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package expression
+
+import (
+	"testing"
+
+	"github.com/pingcap/tidb/ast"
+	"github.com/pingcap/tidb/mysql"
+	"github.com/pingcap/tidb/util/types"
+)
+
+// ternaryVal is one of the three SQL truth values, used to build the 3x3
+// tables below without repeating (val bool, isNull bool) pairs everywhere.
+type ternaryVal int
+
+const (
+	ternaryTrue ternaryVal = iota
+	ternaryFalse
+	ternaryNull
+)
+
+func (v ternaryVal) expr() Expression {
+	switch v {
+	case ternaryTrue:
+		return &Constant{Value: types.NewDatum(int64(1)), RetType: types.NewFieldType(mysql.TypeTiny)}
+	case ternaryFalse:
+		return &Constant{Value: types.NewDatum(int64(0)), RetType: types.NewFieldType(mysql.TypeTiny)}
+	default:
+		return &Constant{Value: types.Datum{}, RetType: types.NewFieldType(mysql.TypeTiny)}
+	}
+}
+
+func (v ternaryVal) String() string {
+	switch v {
+	case ternaryTrue:
+		return "TRUE"
+	case ternaryFalse:
+		return "FALSE"
+	default:
+		return "NULL"
+	}
+}
+
+var allTernaryVals = []ternaryVal{ternaryTrue, ternaryFalse, ternaryNull}
+
+func wantAnd(l, r ternaryVal) ternaryVal {
+	if l == ternaryFalse || r == ternaryFalse {
+		return ternaryFalse
+	}
+	if l == ternaryNull || r == ternaryNull {
+		return ternaryNull
+	}
+	return ternaryTrue
+}
+
+func wantOr(l, r ternaryVal) ternaryVal {
+	if l == ternaryTrue || r == ternaryTrue {
+		return ternaryTrue
+	}
+	if l == ternaryNull || r == ternaryNull {
+		return ternaryNull
+	}
+	return ternaryFalse
+}
+
+func wantNot(v ternaryVal) ternaryVal {
+	switch v {
+	case ternaryTrue:
+		return ternaryFalse
+	case ternaryFalse:
+		return ternaryTrue
+	default:
+		return ternaryNull
+	}
+}
+
+func evalTernaryVal(t *testing.T, expr Expression) ternaryVal {
+	val, isNull, err := EvalTernary(expr, nil, nil)
+	if err != nil {
+		t.Fatalf("EvalTernary(%v) returned error: %v", expr, err)
+	}
+	if isNull {
+		return ternaryNull
+	}
+	if val {
+		return ternaryTrue
+	}
+	return ternaryFalse
+}
+
+func TestEvalTernaryAndOr(t *testing.T) {
+	for _, l := range allTernaryVals {
+		for _, r := range allTernaryVals {
+			and, err := NewFunction(ast.AndAnd, types.NewFieldType(mysql.TypeTiny), l.expr(), r.expr())
+			if err != nil {
+				t.Fatalf("NewFunction(and, %v, %v) returned error: %v", l, r, err)
+			}
+			if got, want := evalTernaryVal(t, and), wantAnd(l, r); got != want {
+				t.Errorf("%v AND %v = %v, want %v", l, r, got, want)
+			}
+
+			or, err := NewFunction(ast.OrOr, types.NewFieldType(mysql.TypeTiny), l.expr(), r.expr())
+			if err != nil {
+				t.Fatalf("NewFunction(or, %v, %v) returned error: %v", l, r, err)
+			}
+			if got, want := evalTernaryVal(t, or), wantOr(l, r); got != want {
+				t.Errorf("%v OR %v = %v, want %v", l, r, got, want)
+			}
+		}
+	}
+}
+
+func TestEvalTernaryNot(t *testing.T) {
+	for _, v := range allTernaryVals {
+		not, err := NewFunction(ast.UnaryNot, types.NewFieldType(mysql.TypeTiny), v.expr())
+		if err != nil {
+			t.Fatalf("NewFunction(not, %v) returned error: %v", v, err)
+		}
+		if got, want := evalTernaryVal(t, not), wantNot(v); got != want {
+			t.Errorf("NOT %v = %v, want %v", v, got, want)
+		}
+	}
+}